@@ -0,0 +1,188 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+var (
+	defaultBatchLimit      = 10000
+	defaultBatchInterval   = 100 * time.Millisecond
+	defaultBatchBytes      = 10 * 1024 * 1024
+	defaultBatchQueueLimit = 10000
+)
+
+// Backend is the storage layer etcd's mvcc, lease and auth stores build on.
+// It wraps a single bolt database and batches writes into it to amortize
+// the cost of fsync.
+type Backend interface {
+	// BatchTx returns the backend's shared write transaction. Callers
+	// must Lock/Unlock it themselves around any Unsafe* calls.
+	BatchTx() BatchTx
+
+	// ReadTx returns the backend's shared read-only transaction. Reads
+	// through it take the ReadTx lock, not the BatchTx lock. It is rolled
+	// over whenever BatchTx commits; that commit takes the ReadTx lock
+	// briefly to do so.
+	ReadTx() ReadTx
+
+	// Batch runs fn under the shared BatchTx, coalescing it with any
+	// other closures submitted concurrently into a single Commit.
+	Batch(fn func(BatchTx) error) error
+
+	Size() int64
+	SizeInUse() int64
+	ForceCommit()
+	Close() error
+}
+
+// BackendConfig holds the parameters to construct a backend.
+type BackendConfig struct {
+	// Path is the file path to the backend file.
+	Path string
+	// BatchInterval is the maximum time before the pending batch is committed.
+	BatchInterval time.Duration
+	// BatchLimit is the maximum number of pending bolt operations
+	// (UnsafePut/UnsafeDelete/...) before the pending batch is committed.
+	BatchLimit int
+	// BatchBytes is the maximum cumulative size, in bytes, of pending
+	// keys and values before the pending batch is committed, regardless
+	// of BatchLimit.
+	BatchBytes int
+	// BatchQueueLimit is the maximum number of closures Batch queues up
+	// before flushing them as a group, independent of BatchLimit: the
+	// two count different things (bolt ops vs. whole closures) and are
+	// tuned separately.
+	BatchQueueLimit int
+}
+
+// DefaultBackendConfig returns a BackendConfig with the recommended defaults.
+func DefaultBackendConfig() BackendConfig {
+	return BackendConfig{
+		BatchInterval:   defaultBatchInterval,
+		BatchLimit:      defaultBatchLimit,
+		BatchBytes:      defaultBatchBytes,
+		BatchQueueLimit: defaultBatchQueueLimit,
+	}
+}
+
+type backend struct {
+	size      int64
+	sizeInUse int64
+	commits   int64
+
+	mu sync.RWMutex
+	db *bolt.DB
+
+	batchInterval time.Duration
+	batchLimit    int
+	batchBytes    int64
+	batchTx       *batchTx
+	readTx        *readTx
+
+	batchQueueLimit int
+	batchMu         sync.Mutex
+	batch           []*batchCall
+	batchTimer      *time.Timer
+
+	stopc chan struct{}
+	donec chan struct{}
+}
+
+// New creates a new backend from the given config.
+func New(bcfg BackendConfig) Backend {
+	return newBackend(bcfg)
+}
+
+// NewDefaultBackend creates a new backend for the given file path using
+// DefaultBackendConfig.
+func NewDefaultBackend(path string) Backend {
+	bcfg := DefaultBackendConfig()
+	bcfg.Path = path
+	return newBackend(bcfg)
+}
+
+func newBackend(bcfg BackendConfig) *backend {
+	db, err := bolt.Open(bcfg.Path, 0600, nil)
+	if err != nil {
+		plog.Panicf("cannot open database at %s (%v)", bcfg.Path, err)
+	}
+
+	b := &backend{
+		db: db,
+
+		batchInterval: bcfg.BatchInterval,
+		batchLimit:    bcfg.BatchLimit,
+		batchBytes:    int64(bcfg.BatchBytes),
+
+		batchQueueLimit: bcfg.BatchQueueLimit,
+
+		stopc: make(chan struct{}),
+		donec: make(chan struct{}),
+	}
+	b.readTx = newReadTx(b)
+	b.batchTx = newBatchTx(b)
+	go b.run()
+	return b
+}
+
+// run periodically checks whether the batchTx has pending writes and its
+// last commit was more than batchInterval ago and, if so, commits it -- this is the trigger for
+// workloads whose writes are too infrequent to ever cross BatchLimit or
+// BatchBytes on their own.
+func (b *backend) run() {
+	defer close(b.donec)
+	t := time.NewTicker(b.batchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-b.stopc:
+			return
+		}
+		if b.batchTx.stale() {
+			commitTriggerTotal.WithLabelValues("interval").Inc()
+			b.batchTx.Commit()
+		}
+	}
+}
+
+func (b *backend) BatchTx() BatchTx { return b.batchTx }
+
+func (b *backend) ReadTx() ReadTx { return b.readTx }
+
+func (b *backend) Size() int64 { return atomic.LoadInt64(&b.size) }
+
+func (b *backend) SizeInUse() int64 { return atomic.LoadInt64(&b.sizeInUse) }
+
+func (b *backend) ForceCommit() { b.batchTx.Commit() }
+
+func (b *backend) Close() error {
+	close(b.stopc)
+	<-b.donec
+
+	b.batchTx.CommitAndStop()
+
+	b.readTx.Lock()
+	b.readTx.Rollback()
+	b.readTx.Unlock()
+
+	return b.db.Close()
+}
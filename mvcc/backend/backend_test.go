@@ -0,0 +1,73 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBatchTxCommitsOnByteLimit checks that a single put whose key+value
+// exceeds BatchBytes is committed by Unlock even though it is only one
+// pending operation, well under BatchLimit.
+func TestBatchTxCommitsOnByteLimit(t *testing.T) {
+	b, cleanup := newTestBackend(t, func(bcfg *BackendConfig) {
+		bcfg.BatchLimit = 10000
+		bcfg.BatchBytes = 8
+	})
+	defer cleanup()
+
+	tx := b.BatchTx()
+	tx.Lock()
+	tx.UnsafePut(testBucket, []byte("k"), []byte("0123456789"))
+	tx.Unlock()
+
+	btx := tx.(*batchTx)
+	btx.Lock()
+	defer btx.Unlock()
+	if btx.pending != 0 {
+		t.Errorf("pending = %d, want 0: Unlock should have committed once BatchBytes was crossed", btx.pending)
+	}
+}
+
+// TestBackendCommitsOnInterval checks that the backend's interval
+// goroutine commits a pending write that never crosses BatchLimit or
+// BatchBytes once BatchInterval has elapsed.
+func TestBackendCommitsOnInterval(t *testing.T) {
+	b, cleanup := newTestBackend(t, func(bcfg *BackendConfig) {
+		bcfg.BatchInterval = 5 * time.Millisecond
+		bcfg.BatchLimit = 10000
+		bcfg.BatchBytes = 10000
+	})
+	defer cleanup()
+
+	tx := b.BatchTx()
+	tx.Lock()
+	tx.UnsafePut(testBucket, []byte("k"), []byte("v"))
+	tx.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		btx := tx.(*batchTx)
+		btx.Lock()
+		pending := btx.pending
+		btx.Unlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("pending write was never committed by the interval goroutine")
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"fmt"
+	"time"
+)
+
+// batchCall is one closure submitted to backend.Batch, queued up alongside
+// others so they can share a single underlying Commit.
+type batchCall struct {
+	fn   func(BatchTx) error
+	err  error
+	errc chan error
+}
+
+// Batch runs fn, coalescing it with any other closures submitted
+// concurrently into a single Lock/Commit/Unlock cycle of the backend's
+// BatchTx, mirroring bbolt's DB.Batch. This lets callers like the lease
+// and auth stores amortize fsync cost without reaching for BatchTx
+// directly.
+//
+// The first caller to arrive starts a BatchInterval timer; the batch is
+// run as soon as that timer fires or BatchQueueLimit closures have queued,
+// whichever comes first. If any closure in the batch panics or returns an
+// error, the whole batch is discarded: the closure that failed keeps that
+// result, and every other (previously "successful") closure is re-run
+// individually, since the tx it ran against never committed. No closure
+// runs more than once unless it is a genuine survivor being retried.
+func (b *backend) Batch(fn func(BatchTx) error) error {
+	c := &batchCall{fn: fn, errc: make(chan error, 1)}
+
+	b.batchMu.Lock()
+	b.batch = append(b.batch, c)
+	switch {
+	case len(b.batch) == 1:
+		b.batchTimer = time.AfterFunc(b.batchInterval, b.flushBatch)
+	case len(b.batch) >= b.batchQueueLimit:
+		b.batchTimer.Stop()
+		go b.flushBatch()
+	}
+	b.batchMu.Unlock()
+
+	return <-c.errc
+}
+
+// flushBatch takes ownership of the currently queued calls and runs them.
+func (b *backend) flushBatch() {
+	b.batchMu.Lock()
+	calls := b.batch
+	b.batch = nil
+	b.batchMu.Unlock()
+
+	b.runBatch(calls)
+}
+
+// runBatch runs calls under a single Lock/Commit/Unlock cycle of the
+// backend's batchTx. If every closure succeeds, the shared commit result
+// (nil) is fanned out to each caller; otherwise the batch is abandoned,
+// the closure that failed is resolved with that failure, and the rest are
+// re-run one at a time via runIndividually.
+func (b *backend) runBatch(calls []*batchCall) {
+	if len(calls) == 0 {
+		return
+	}
+
+	tx := b.batchTx
+	tx.Lock()
+	failed := false
+	for _, c := range calls {
+		if !safeBatchCall(c, tx) {
+			failed = true
+		}
+	}
+	if failed {
+		// Bolt cannot commit part of a transaction, so a single bad
+		// closure poisons everything this pass wrote -- successful
+		// closures included. Roll the whole tx back and unlock the
+		// mutex directly: tx.Unlock()'s own threshold check must not
+		// run here, or it could commit this still-dirty, abandoned tx.
+		tx.rollbackAndRestart()
+		tx.Mutex.Unlock()
+
+		// c.err already holds a terminal result for anything that
+		// panicked or errored on this pass -- it must not run again.
+		// Only the survivors, whose (uncommitted, now-rolled-back)
+		// success can't be trusted, get retried.
+		survivors := calls[:0:0]
+		for _, c := range calls {
+			if c.err == nil {
+				survivors = append(survivors, c)
+			} else {
+				c.errc <- c.err
+			}
+		}
+		b.runIndividually(survivors)
+		return
+	}
+
+	tx.commit(false)
+	tx.Unlock()
+
+	for _, c := range calls {
+		c.errc <- c.err
+	}
+}
+
+// runIndividually re-runs each call in its own Lock/Commit/Unlock cycle so
+// a single failing closure cannot fail the others.
+func (b *backend) runIndividually(calls []*batchCall) {
+	tx := b.batchTx
+	for _, c := range calls {
+		tx.Lock()
+		safeBatchCall(c, tx)
+		tx.commit(false)
+		tx.Unlock()
+		c.errc <- c.err
+	}
+}
+
+// safeBatchCall runs c.fn, recovering from any panic and recording the
+// outcome on c. It reports whether c.fn completed without panicking or
+// returning an error.
+func safeBatchCall(c *batchCall, tx BatchTx) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("backend: batch closure panicked: %v", r)
+			ok = false
+		}
+	}()
+	c.err = c.fn(tx)
+	return c.err == nil
+}
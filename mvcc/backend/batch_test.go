@@ -0,0 +1,161 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testBucket = []byte("test")
+
+// newTestBackend creates a backend over a temporary file for use in tests.
+// mutate, if non-nil, is given the chance to tweak the config (e.g. to
+// force a particular commit trigger) before the backend is opened.
+func newTestBackend(t *testing.T, mutate func(*BackendConfig)) (*backend, func()) {
+	t.Helper()
+
+	tmpDir, err := ioutil.TempDir("", "etcd_backend_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcfg := DefaultBackendConfig()
+	bcfg.Path = filepath.Join(tmpDir, "database")
+	bcfg.BatchInterval = 10 * time.Millisecond
+	if mutate != nil {
+		mutate(&bcfg)
+	}
+
+	b := newBackend(bcfg)
+
+	tx := b.BatchTx()
+	tx.Lock()
+	tx.UnsafeCreateBucket(testBucket)
+	tx.Unlock()
+
+	return b, func() {
+		b.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestBackendBatchCommitsSuccessfulClosures(t *testing.T) {
+	b, cleanup := newTestBackend(t, nil)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	n := 5
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			if err := b.Batch(func(tx BatchTx) error {
+				tx.UnsafePut(testBucket, []byte(fmt.Sprintf("k%d", i)), []byte("v"))
+				return nil
+			}); err != nil {
+				t.Errorf("Batch: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rtx := b.ReadTx()
+	rtx.Lock()
+	defer rtx.Unlock()
+	for i := 0; i < n; i++ {
+		keys, _ := rtx.UnsafeRange(testBucket, []byte(fmt.Sprintf("k%d", i)), nil, 0)
+		if len(keys) != 1 {
+			t.Errorf("key k%d: want present once, got %d copies", i, len(keys))
+		}
+	}
+}
+
+// TestBackendBatchFailureDoesNotDoubleApply drives runBatch directly (bypassing
+// Batch's queueing and timer, which give no guarantee all n calls land in a
+// single pass) with one closure that fails alongside several that succeed. It
+// asserts each closure runs the expected number of times: the closure that
+// failed already has a terminal result and must run exactly once, while the
+// survivors' success couldn't be committed (the whole tx was rolled back) and
+// must be retried exactly once via runIndividually -- twice total, never more.
+func TestBackendBatchFailureDoesNotDoubleApply(t *testing.T) {
+	b, cleanup := newTestBackend(t, nil)
+	defer cleanup()
+
+	const n = 5
+	const failIdx = 2
+
+	counts := make([]int32, n)
+	calls := make([]*batchCall, n)
+	for i := 0; i < n; i++ {
+		i := i
+		calls[i] = &batchCall{
+			errc: make(chan error, 1),
+			fn: func(tx BatchTx) error {
+				atomic.AddInt32(&counts[i], 1)
+				if i == failIdx {
+					return fmt.Errorf("closure %d failed", i)
+				}
+				tx.UnsafePut(testBucket, []byte(fmt.Sprintf("k%d", i)), []byte("v"))
+				return nil
+			},
+		}
+	}
+
+	b.runBatch(calls)
+
+	for i, c := range calls {
+		var err error
+		select {
+		case err = <-c.errc:
+		default:
+			t.Fatalf("closure %d: runBatch never resolved its error channel", i)
+		}
+		if wantErr := i == failIdx; (err != nil) != wantErr {
+			t.Errorf("closure %d: err = %v, want error = %v", i, err, wantErr)
+		}
+
+		want := int32(2)
+		if i == failIdx {
+			want = 1
+		}
+		if got := atomic.LoadInt32(&counts[i]); got != want {
+			t.Errorf("closure %d ran %d times, want %d", i, got, want)
+		}
+	}
+
+	rtx := b.ReadTx()
+	rtx.Lock()
+	defer rtx.Unlock()
+	for i := 0; i < n; i++ {
+		keys, _ := rtx.UnsafeRange(testBucket, []byte(fmt.Sprintf("k%d", i)), nil, 0)
+		if i == failIdx {
+			if len(keys) != 0 {
+				t.Errorf("key k%d: want absent, the closure that wrote it returned an error", i)
+			}
+			continue
+		}
+		if len(keys) != 1 {
+			t.Errorf("key k%d: want present exactly once, got %d copies", i, len(keys))
+		}
+	}
+}
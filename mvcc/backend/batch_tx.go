@@ -30,6 +30,7 @@ type BatchTx interface {
 	UnsafePut(bucketName []byte, key []byte, value []byte)
 	UnsafeSeqPut(bucketName []byte, key []byte, value []byte)
 	UnsafeRange(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte)
+	UnsafeRangeReverse(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte)
 	UnsafeDelete(bucketName []byte, key []byte)
 	UnsafeForEach(bucketName []byte, visitor func(k, v []byte) error) error
 	Commit()
@@ -40,7 +41,10 @@ type batchTx struct {
 	sync.Mutex
 	tx      *bolt.Tx
 	backend *backend
-	pending int
+
+	pending      int
+	pendingBytes int64
+	lastCommit   time.Time
 }
 
 func newBatchTx(backend *backend) *batchTx {
@@ -81,11 +85,23 @@ func (t *batchTx) unsafePut(bucketName []byte, key []byte, value []byte, seq boo
 		plog.Fatalf("cannot put key into bucket (%v)", err)
 	}
 	t.pending++
+	t.pendingBytes += int64(len(key) + len(value))
 }
 
 // UnsafeRange must be called holding the lock on the tx.
 func (t *batchTx) UnsafeRange(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vs [][]byte) {
-	bucket := t.tx.Bucket(bucketName)
+	return unsafeRange(t.tx, bucketName, key, endKey, limit)
+}
+
+// UnsafeRangeReverse must be called holding the lock on the tx.
+func (t *batchTx) UnsafeRangeReverse(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vs [][]byte) {
+	return unsafeRangeReverse(t.tx, bucketName, key, endKey, limit)
+}
+
+// unsafeRange walks bucketName forward from key up to (but not including)
+// endKey. It backs both batchTx.UnsafeRange and readTx.UnsafeRange.
+func unsafeRange(tx *bolt.Tx, bucketName, key, endKey []byte, limit int64) (keys [][]byte, vs [][]byte) {
+	bucket := tx.Bucket(bucketName)
 	if bucket == nil {
 		plog.Fatalf("bucket %s does not exist", bucketName)
 	}
@@ -110,6 +126,44 @@ func (t *batchTx) UnsafeRange(bucketName []byte, key, endKey []byte, limit int64
 	return keys, vs
 }
 
+// unsafeRangeReverse is the mirror image of unsafeRange: it walks
+// bucketName backward, starting just below endKey (or at the last key in
+// the bucket if no key is at or above endKey), down to and including key.
+// As with unsafeRange, an empty endKey looks up key alone. It lets
+// callers such as the mvcc store's revision scans read newest-first
+// without buffering and reversing a forward range in Go.
+func unsafeRangeReverse(tx *bolt.Tx, bucketName, key, endKey []byte, limit int64) (keys [][]byte, vs [][]byte) {
+	bucket := tx.Bucket(bucketName)
+	if bucket == nil {
+		plog.Fatalf("bucket %s does not exist", bucketName)
+	}
+
+	if len(endKey) == 0 {
+		if v := bucket.Get(key); v == nil {
+			return keys, vs
+		} else {
+			return append(keys, key), append(vs, v)
+		}
+	}
+
+	c := bucket.Cursor()
+	ck, cv := c.Seek(endKey)
+	if ck == nil {
+		ck, cv = c.Last()
+	} else {
+		ck, cv = c.Prev()
+	}
+	for ; ck != nil && bytes.Compare(ck, key) >= 0; ck, cv = c.Prev() {
+		keys = append(keys, ck)
+		vs = append(vs, cv)
+		if limit > 0 && limit == int64(len(keys)) {
+			break
+		}
+	}
+
+	return keys, vs
+}
+
 // UnsafeDelete must be called holding the lock on the tx.
 func (t *batchTx) UnsafeDelete(bucketName []byte, key []byte) {
 	bucket := t.tx.Bucket(bucketName)
@@ -121,6 +175,7 @@ func (t *batchTx) UnsafeDelete(bucketName []byte, key []byte) {
 		plog.Fatalf("cannot delete key from bucket (%v)", err)
 	}
 	t.pending++
+	t.pendingBytes += int64(len(key))
 }
 
 // UnsafeForEach must be called holding the lock on the tx.
@@ -148,13 +203,59 @@ func (t *batchTx) CommitAndStop() {
 }
 
 func (t *batchTx) Unlock() {
-	if t.pending >= t.backend.batchLimit {
+	if trigger := t.commitTriggerLocked(); trigger != "" {
+		commitTriggerTotal.WithLabelValues(trigger).Inc()
 		t.commit(false)
-		t.pending = 0
 	}
 	t.Mutex.Unlock()
 }
 
+// commitTriggerLocked reports which configured threshold, if any, the
+// pending write has crossed, so Unlock knows whether to force a commit and
+// so operators can see which knob (BatchLimit or BatchBytes) is firing for
+// a given workload. It must be called holding the lock on the tx.
+func (t *batchTx) commitTriggerLocked() string {
+	switch {
+	case t.pending >= t.backend.batchLimit:
+		return "pending-ops"
+	case t.pendingBytes >= t.backend.batchBytes:
+		return "pending-bytes"
+	default:
+		return ""
+	}
+}
+
+// stale reports whether the tx has uncommitted writes and the last commit
+// was more than the backend's BatchInterval ago.
+func (t *batchTx) stale() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.pending > 0 && time.Since(t.lastCommit) > t.backend.batchInterval
+}
+
+// rollbackAndRestart discards every uncommitted write on the tx and begins
+// a fresh one in its place. Bolt has no way to roll back only part of a
+// transaction, so this is the only safe way to discard a batch that failed
+// partway through: callers must not rely on any writes made since the last
+// Commit once they call this. It must be called holding the lock on the tx.
+func (t *batchTx) rollbackAndRestart() {
+	if t.tx != nil {
+		if err := t.tx.Rollback(); err != nil {
+			plog.Fatalf("cannot rollback tx (%s)", err)
+		}
+	}
+	t.pending = 0
+	t.pendingBytes = 0
+
+	t.backend.mu.RLock()
+	defer t.backend.mu.RUnlock()
+	tx, err := t.backend.db.Begin(true)
+	if err != nil {
+		plog.Fatalf("cannot begin tx (%s)", err)
+	}
+	t.tx = tx
+}
+
 func (t *batchTx) commit(stop bool) {
 	var err error
 	// commit the last tx
@@ -172,9 +273,17 @@ func (t *batchTx) commit(stop bool) {
 		atomic.AddInt64(&t.backend.commits, 1)
 
 		t.pending = 0
+		t.pendingBytes = 0
+		t.lastCommit = time.Now()
 		if err != nil {
 			plog.Fatalf("cannot commit tx (%s)", err)
 		}
+
+		// roll the read-only tx over on the same cadence as the write
+		// tx so bolt's freelist can reclaim the pages it pins.
+		t.backend.readTx.Lock()
+		t.backend.readTx.Rollback()
+		t.backend.readTx.Unlock()
 	}
 
 	if stop {
@@ -0,0 +1,81 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBatchTxUnsafeRangeReverse(t *testing.T) {
+	b, cleanup := newTestBackend(t, nil)
+	defer cleanup()
+
+	tx := b.BatchTx()
+	tx.Lock()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tx.UnsafePut(testBucket, []byte(k), []byte("v-"+k))
+	}
+	tx.Unlock()
+	tx.Commit()
+
+	keys, vals := tx.UnsafeRangeReverse(testBucket, []byte("a"), []byte("d"), 0)
+	wantKeys := []string{"c", "b", "a"}
+	if len(keys) != len(wantKeys) {
+		t.Fatalf("got %d keys, want %d (%v)", len(keys), len(wantKeys), keys)
+	}
+	for i, k := range wantKeys {
+		if !bytes.Equal(keys[i], []byte(k)) {
+			t.Errorf("key %d: got %q, want %q", i, keys[i], k)
+		}
+		if !bytes.Equal(vals[i], []byte("v-"+k)) {
+			t.Errorf("val %d: got %q, want %q", i, vals[i], "v-"+k)
+		}
+	}
+
+	if keys, _ := tx.UnsafeRangeReverse(testBucket, []byte("a"), []byte("d"), 2); len(keys) != 2 {
+		t.Errorf("limit 2: got %d keys, want 2", len(keys))
+	}
+}
+
+// TestReadTxSeesCommittedDataAfterRollover checks that a ReadTx opened
+// before a key is written does not see the key then, and that it does see
+// it once the write commits, since commit() rolls the read tx over on the
+// same cadence as the write tx.
+func TestReadTxSeesCommittedDataAfterRollover(t *testing.T) {
+	b, cleanup := newTestBackend(t, nil)
+	defer cleanup()
+
+	rtx := b.ReadTx()
+	rtx.Lock()
+	keys, _ := rtx.UnsafeRange(testBucket, []byte("k"), nil, 0)
+	rtx.Unlock()
+	if len(keys) != 0 {
+		t.Fatalf("key present before it was ever written")
+	}
+
+	tx := b.BatchTx()
+	tx.Lock()
+	tx.UnsafePut(testBucket, []byte("k"), []byte("v"))
+	tx.Unlock()
+	tx.Commit()
+
+	rtx.Lock()
+	defer rtx.Unlock()
+	keys, vals := rtx.UnsafeRange(testBucket, []byte("k"), nil, 0)
+	if len(keys) != 1 || !bytes.Equal(vals[0], []byte("v")) {
+		t.Fatalf("got keys=%v vals=%v, want [k]=v after rollover", keys, vals)
+	}
+}
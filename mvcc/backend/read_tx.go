@@ -0,0 +1,95 @@
+// Copyright 2015 The etcd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"sync"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+// ReadTx is a read-only view onto the backend that is not blocked by the
+// writer's Commit cadence. Unlike BatchTx, it never becomes a write
+// transaction; it stays open across many callers until it is explicitly
+// Rollback'd, at which point the next Unsafe* call begins a fresh bolt
+// transaction.
+type ReadTx interface {
+	Lock()
+	Unlock()
+
+	UnsafeRange(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte)
+	UnsafeRangeReverse(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte)
+	UnsafeForEach(bucketName []byte, visitor func(k, v []byte) error) error
+
+	// Rollback ends the underlying bolt transaction, if one is open.
+	Rollback()
+}
+
+type readTx struct {
+	sync.Mutex
+	backend *backend
+	tx      *bolt.Tx
+}
+
+func newReadTx(backend *backend) *readTx {
+	return &readTx{backend: backend}
+}
+
+// begin lazily starts the underlying read-only bolt transaction. It must
+// be called with the readTx lock held.
+func (rt *readTx) begin() {
+	if rt.tx != nil {
+		return
+	}
+	tx, err := rt.backend.db.Begin(false)
+	if err != nil {
+		plog.Fatalf("cannot begin read tx (%s)", err)
+	}
+	rt.tx = tx
+}
+
+// UnsafeRange must be called holding the lock on the tx.
+func (rt *readTx) UnsafeRange(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte) {
+	rt.begin()
+	return unsafeRange(rt.tx, bucketName, key, endKey, limit)
+}
+
+// UnsafeRangeReverse must be called holding the lock on the tx.
+func (rt *readTx) UnsafeRangeReverse(bucketName []byte, key, endKey []byte, limit int64) (keys [][]byte, vals [][]byte) {
+	rt.begin()
+	return unsafeRangeReverse(rt.tx, bucketName, key, endKey, limit)
+}
+
+// UnsafeForEach must be called holding the lock on the tx.
+func (rt *readTx) UnsafeForEach(bucketName []byte, visitor func(k, v []byte) error) error {
+	rt.begin()
+	b := rt.tx.Bucket(bucketName)
+	if b == nil {
+		// bucket does not exist
+		return nil
+	}
+	return b.ForEach(visitor)
+}
+
+// Rollback must be called holding the lock on the tx.
+func (rt *readTx) Rollback() {
+	if rt.tx == nil {
+		return
+	}
+	if err := rt.tx.Rollback(); err != nil {
+		plog.Fatalf("cannot rollback read tx (%s)", err)
+	}
+	rt.tx = nil
+}